@@ -0,0 +1,56 @@
+package server
+
+// Batch is a window of decoded events read from a single client
+// connection. The events must be ACKed once fully processed so the server
+// can send the final ACK and continue reading the next window. Callers
+// that process events incrementally may call ACKEvents as they go to have
+// the server send intermediate ACKs for the events processed so far,
+// instead of holding the whole window until ACK.
+type Batch struct {
+	// Version is the protocol version byte (protocol.CodeVersion or
+	// protocol.CodeVersion1) the batch was received with.
+	Version byte
+
+	// Events holds the decoded events, in the order they were sent. An
+	// event from a JSON data frame is whatever the configured JSON decoder
+	// produced; one from a v1 key/value data frame is a map[string]string
+	// of the flat key/value pairs; one from a CBOR, MessagePack or
+	// Protobuf data frame is a RawEvent.
+	Events []interface{}
+
+	ackCh      chan struct{}
+	progressCh chan uint32
+}
+
+// RawEvent is a decoded data frame whose content code is not JSON or the
+// legacy v1 key/value format (for example CBOR, MessagePack or
+// Protobuf). The server does not decode these itself; callers should
+// decode Payload with a codec matching ContentCode.
+type RawEvent struct {
+	ContentCode byte
+	Payload     []byte
+}
+
+func newBatch(version byte, events []interface{}) *Batch {
+	return &Batch{
+		Version:    version,
+		Events:     events,
+		ackCh:      make(chan struct{}),
+		progressCh: make(chan uint32),
+	}
+}
+
+// ACKEvents reports that n of the batch's events have been processed so
+// far, causing the server to send an intermediate ACK for n to the client
+// without waiting for the rest of the batch. Calls must report strictly
+// increasing values of n, and none may be made after ACK.
+func (b *Batch) ACKEvents(n uint32) {
+	b.progressCh <- n
+}
+
+// ACK acknowledges the batch has been fully processed. The server will
+// send the final ACK for the batch back to the client and resume reading
+// from the connection. ACK must only be called once per batch.
+func (b *Batch) ACK() {
+	close(b.ackCh)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	cborlib "github.com/fxamacker/cbor/v2"
+	msgpacklib "github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/urso/go-lumber/v2/client"
+	"github.com/urso/go-lumber/v2/codec/cbor"
+	"github.com/urso/go-lumber/v2/codec/msgpack"
+	"github.com/urso/go-lumber/v2/codec/protobuf"
+)
+
+// TestHandleConnCodecRoundTrip verifies that an event encoded by each
+// client.Codec implementation arrives at the server as a RawEvent with
+// the codec's ContentCode, and that its Payload decodes back to the
+// original event using that codec's own wire format.
+func TestHandleConnCodecRoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		codec  client.Codec
+		event  interface{}
+		decode func(t *testing.T, payload []byte)
+	}{
+		{
+			name:  "cbor",
+			codec: cbor.New(),
+			event: map[string]interface{}{"message": "hello"},
+			decode: func(t *testing.T, payload []byte) {
+				var got map[string]interface{}
+				if err := cborlib.Unmarshal(payload, &got); err != nil {
+					t.Fatalf("payload did not decode as CBOR: %v", err)
+				}
+				if got["message"] != "hello" {
+					t.Fatalf("message = %v, want hello", got["message"])
+				}
+			},
+		},
+		{
+			name:  "msgpack",
+			codec: msgpack.New(),
+			event: map[string]interface{}{"message": "hello"},
+			decode: func(t *testing.T, payload []byte) {
+				var got map[string]interface{}
+				if err := msgpacklib.Unmarshal(payload, &got); err != nil {
+					t.Fatalf("payload did not decode as MessagePack: %v", err)
+				}
+				if got["message"] != "hello" {
+					t.Fatalf("message = %v, want hello", got["message"])
+				}
+			},
+		},
+		{
+			name:  "protobuf",
+			codec: protobuf.New(),
+			event: wrapperspb.String("hello"),
+			decode: func(t *testing.T, payload []byte) {
+				var got wrapperspb.StringValue
+				if err := proto.Unmarshal(payload, &got); err != nil {
+					t.Fatalf("payload did not decode as a protobuf message: %v", err)
+				}
+				if got.GetValue() != "hello" {
+					t.Fatalf("value = %q, want hello", got.GetValue())
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, srv := net.Pipe()
+			defer clientConn.Close()
+
+			s := newTestServer(t)
+			go s.handleConn(srv)
+
+			c, err := client.NewWithConn(clientConn, client.WithCodec(tc.codec))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sendErr := make(chan error, 1)
+			go func() {
+				sendErr <- c.Send([]interface{}{tc.event})
+			}()
+
+			batch := <-s.ch
+			if len(batch.Events) != 1 {
+				t.Fatalf("expected 1 event, got %d", len(batch.Events))
+			}
+			raw, ok := batch.Events[0].(RawEvent)
+			if !ok {
+				t.Fatalf("event is %T, want RawEvent", batch.Events[0])
+			}
+			if raw.ContentCode != tc.codec.ContentCode() {
+				t.Fatalf("ContentCode = %v, want %v", raw.ContentCode, tc.codec.ContentCode())
+			}
+			tc.decode(t, raw.Payload)
+			batch.ACK()
+
+			if err := <-sendErr; err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if _, err := c.AwaitACK(1); err != nil {
+				t.Fatalf("AwaitACK: %v", err)
+			}
+		})
+	}
+}
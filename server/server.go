@@ -0,0 +1,515 @@
+// Package server implements a Lumberjack protocol server. It accepts
+// connections from Lumberjack clients (for example beats or the
+// logstash-forwarder), decodes v1 and v2 framed events and hands decoded
+// batches to the caller via ReceiveChan, sending partial and final ACKs
+// back to the client as batches are acknowledged.
+package server
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/urso/go-lumber/v2/protocol"
+)
+
+// Server accepts Lumberjack connections on a net.Listener and decodes
+// incoming batches of events.
+type Server struct {
+	lis  net.Listener
+	opts options
+
+	ch  chan *Batch
+	err chan error
+}
+
+type options struct {
+	timeout             time.Duration
+	decoder             jsonDecoder
+	tls                 *tls.Config
+	maxWindowSize       uint32
+	maxPayloadSize      uint32
+	maxDecompressedSize uint32
+}
+
+type jsonDecoder func([]byte, interface{}) error
+
+// Option type to be passed to New/Listen functions.
+type Option func(*options) error
+
+// Defaults for the server's DoS guards: a client announcing a window or
+// frame length above these has its connection closed before anything is
+// allocated for it.
+const (
+	defaultMaxWindowSize       = 10000
+	defaultMaxPayloadSize      = 10 * 1024 * 1024  // 10MiB
+	defaultMaxDecompressedSize = 100 * 1024 * 1024 // 100MiB
+)
+
+var (
+	// ErrProtocolError is returned if a protocol error was detected in the
+	// conversation with a lumberjack client.
+	ErrProtocolError = errors.New("lumberjack protocol error")
+
+	// ErrWindowTooLarge is returned if a client announces a window size
+	// (event count) above MaxWindowSize.
+	ErrWindowTooLarge = errors.New("lumberjack window size too large")
+
+	// ErrFrameTooLarge is returned if a single frame's payload length (or,
+	// for a v1 data frame, its pair count) exceeds MaxPayloadSize, or a
+	// compressed frame would decompress to more than MaxDecompressedSize.
+	ErrFrameTooLarge = errors.New("lumberjack frame length too large")
+)
+
+// JSONDecoder server option configuring the decoder used to parse v2 JSON
+// payloads.
+func JSONDecoder(decoder func([]byte, interface{}) error) Option {
+	return func(opt *options) error {
+		opt.decoder = decoder
+		return nil
+	}
+}
+
+// Timeout server option configuring the read/write timeout used on
+// accepted connections.
+func Timeout(to time.Duration) Option {
+	return func(opt *options) error {
+		if to < 0 {
+			return errors.New("timeouts must not be negative")
+		}
+		opt.timeout = to
+		return nil
+	}
+}
+
+// TLS server option wrapping the listener with the given TLS
+// configuration.
+func TLS(cfg *tls.Config) Option {
+	return func(opt *options) error {
+		opt.tls = cfg
+		return nil
+	}
+}
+
+// MaxWindowSize server option capping the number of events a client may
+// announce in a single window (protocol.CodeWindowSize). Checked before
+// any per-event allocation, so a client cannot force a large upfront
+// allocation merely by sending a large window size. Defaults to 10000.
+func MaxWindowSize(n uint32) Option {
+	return func(opt *options) error {
+		if n == 0 {
+			return errors.New("max window size must be positive")
+		}
+		opt.maxWindowSize = n
+		return nil
+	}
+}
+
+// MaxPayloadSize server option capping the length of any single frame
+// payload (a v2 data frame, a v1 key/value string, or a compressed
+// frame's announced size), checked before a buffer is allocated for it.
+// Defaults to 10MiB.
+func MaxPayloadSize(n uint32) Option {
+	return func(opt *options) error {
+		if n == 0 {
+			return errors.New("max payload size must be positive")
+		}
+		opt.maxPayloadSize = n
+		return nil
+	}
+}
+
+// MaxDecompressedSize server option capping the total number of bytes a
+// single compressed frame may decompress to, guarding against
+// decompression bombs. Defaults to 100MiB.
+func MaxDecompressedSize(n uint32) Option {
+	return func(opt *options) error {
+		if n == 0 {
+			return errors.New("max decompressed size must be positive")
+		}
+		opt.maxDecompressedSize = n
+		return nil
+	}
+}
+
+func applyOptions(opts []Option) (options, error) {
+	o := options{
+		decoder:             json.Unmarshal,
+		timeout:             30 * time.Second,
+		maxWindowSize:       defaultMaxWindowSize,
+		maxPayloadSize:      defaultMaxPayloadSize,
+		maxDecompressedSize: defaultMaxDecompressedSize,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// NewWithListener creates a new Server accepting connections on the given
+// listener.
+func NewWithListener(lis net.Listener, opts ...Option) (*Server, error) {
+	o, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.tls != nil {
+		lis = tls.NewListener(lis, o.tls)
+	}
+
+	s := &Server{
+		lis:  lis,
+		opts: o,
+		ch:   make(chan *Batch),
+		err:  make(chan error, 1),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Listen creates a listener on address and returns a new Server accepting
+// connections on it. Returns error if the listener cannot be created.
+func Listen(network, address string, opts ...Option) (*Server, error) {
+	lis, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewWithListener(lis, opts...)
+	if err != nil {
+		_ = lis.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// ReceiveChan returns the channel batches of decoded events are published
+// on. Every received Batch must eventually be ACKed by the caller, or the
+// connection it originated from will stall.
+func (s *Server) ReceiveChan() <-chan *Batch {
+	return s.ch
+}
+
+// Close closes the underlying listener, causing Accept to fail and the
+// server to stop accepting new connections. Already accepted connections
+// are not closed.
+func (s *Server) Close() error {
+	return s.lis.Close()
+}
+
+func (s *Server) run() {
+	defer close(s.ch)
+
+	for {
+		conn, err := s.lis.Accept()
+		if err != nil {
+			select {
+			case s.err <- err:
+			default:
+			}
+			return
+		}
+
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		if err := s.setReadDeadline(conn); err != nil {
+			return
+		}
+
+		version, code, err := readHeader(conn)
+		if err != nil {
+			return
+		}
+		if version != protocol.CodeVersion && version != protocol.CodeVersion1 {
+			return
+		}
+		if code != protocol.CodeWindowSize {
+			return
+		}
+
+		count, err := readWindowSize(conn, s.opts.maxWindowSize)
+		if err != nil {
+			return
+		}
+
+		events, err := s.readEvents(conn, version, count)
+		if err != nil {
+			return
+		}
+
+		batch := newBatch(version, events)
+		s.ch <- batch
+
+		if err := s.drainACKs(conn, version, batch, count); err != nil {
+			return
+		}
+	}
+}
+
+// drainACKs writes an intermediate ACK to conn for every progress report
+// the caller makes via batch.ACKEvents, then a final ACK for count once
+// the caller calls batch.ACK, so large windows don't have to be held
+// fully in memory by the client until 100% processed.
+func (s *Server) drainACKs(conn net.Conn, version byte, batch *Batch, count uint32) error {
+	for {
+		select {
+		case n := <-batch.progressCh:
+			if err := s.setWriteDeadline(conn); err != nil {
+				return err
+			}
+			if err := writeACK(conn, version, n); err != nil {
+				return err
+			}
+
+		case <-batch.ackCh:
+			if err := s.setWriteDeadline(conn); err != nil {
+				return err
+			}
+			return writeACK(conn, version, count)
+		}
+	}
+}
+
+// readEvents reads count decoded events from r, following CodeCompressed
+// frames transparently by switching to a zlib decompressing reader for the
+// remainder of the window.
+func (s *Server) readEvents(r io.Reader, version byte, count uint32) ([]interface{}, error) {
+	return s.readEventsIn(r, version, count, false)
+}
+
+// readEventsIn is readEvents' worker. compressed reports whether r is
+// already a decompressing reader for a CodeCompressed frame; a nested
+// CodeCompressed within it is rejected rather than recursed into, since a
+// client can otherwise wrap thousands of empty compressed frames inside
+// one another to force the server through that many levels of recursion,
+// each allocating a new events slice sized to the whole remaining window.
+func (s *Server) readEventsIn(r io.Reader, version byte, count uint32, compressed bool) ([]interface{}, error) {
+	events := make([]interface{}, 0, count)
+
+	for uint32(len(events)) < count {
+		v, code, err := readHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if v != version {
+			return nil, ErrProtocolError
+		}
+
+		switch code {
+		case protocol.CodeCompressed:
+			if compressed {
+				return nil, ErrProtocolError
+			}
+
+			sz, err := readUint32(r)
+			if err != nil {
+				return nil, err
+			}
+			if sz > s.opts.maxPayloadSize {
+				return nil, ErrFrameTooLarge
+			}
+
+			zr, err := zlib.NewReader(io.LimitReader(r, int64(sz)))
+			if err != nil {
+				return nil, err
+			}
+			// Cap the decompressed output so a small, highly-compressed
+			// frame cannot force the server to read an unbounded amount
+			// of data (a decompression bomb).
+			limited := &io.LimitedReader{R: zr, N: int64(s.opts.maxDecompressedSize)}
+
+			sub, err := s.readEventsIn(limited, version, count-uint32(len(events)), true)
+			if err != nil {
+				if limited.N == 0 {
+					return nil, ErrFrameTooLarge
+				}
+				return nil, err
+			}
+			events = append(events, sub...)
+
+		case protocol.CodeJSONDataFrame:
+			event, err := s.readJSONDataFrame(r)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+
+		case protocol.CodeDataFrame:
+			event, err := readDataFrame(r, s.opts.maxPayloadSize)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+
+		case protocol.CodeCBORDataFrame, protocol.CodeMsgpackDataFrame, protocol.CodeProtobufDataFrame:
+			event, err := readRawFrame(r, code, s.opts.maxPayloadSize)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+
+		default:
+			return nil, ErrProtocolError
+		}
+	}
+
+	return events, nil
+}
+
+func (s *Server) readJSONDataFrame(r io.Reader) (interface{}, error) {
+	if _, err := readUint32(r); err != nil { // seq, unused: order is implicit
+		return nil, err
+	}
+
+	ln, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if ln > s.opts.maxPayloadSize {
+		return nil, ErrFrameTooLarge
+	}
+
+	payload := make([]byte, ln)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	var event interface{}
+	if err := s.opts.decoder(payload, &event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// readRawFrame reads a data frame whose content code the server does not
+// know how to decode itself (CBOR, MessagePack, Protobuf, ...) and returns
+// it as a RawEvent for the caller to decode with a matching codec.
+func readRawFrame(r io.Reader, code byte, maxPayloadSize uint32) (interface{}, error) {
+	if _, err := readUint32(r); err != nil { // seq, unused: order is implicit
+		return nil, err
+	}
+
+	ln, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if ln > maxPayloadSize {
+		return nil, ErrFrameTooLarge
+	}
+
+	payload := make([]byte, ln)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	return RawEvent{ContentCode: code, Payload: payload}, nil
+}
+
+func readDataFrame(r io.Reader, maxPayloadSize uint32) (interface{}, error) {
+	if _, err := readUint32(r); err != nil { // seq, unused: order is implicit
+		return nil, err
+	}
+
+	pairs, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if pairs > maxPayloadSize {
+		return nil, ErrFrameTooLarge
+	}
+
+	event := make(map[string]string, pairs)
+	for i := uint32(0); i < pairs; i++ {
+		key, err := readString(r, maxPayloadSize)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readString(r, maxPayloadSize)
+		if err != nil {
+			return nil, err
+		}
+
+		event[key] = value
+	}
+	return event, nil
+}
+
+func readString(r io.Reader, maxPayloadSize uint32) (string, error) {
+	ln, err := readUint32(r)
+	if err != nil {
+		return "", err
+	}
+	if ln > maxPayloadSize {
+		return "", ErrFrameTooLarge
+	}
+
+	buf := make([]byte, ln)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func readHeader(r io.Reader) (version, code byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, 0, err
+	}
+	return hdr[0], hdr[1], nil
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
+
+// readWindowSize reads the event count announced by a CodeWindowSize
+// frame, rejecting it with ErrWindowTooLarge before the caller allocates
+// anything sized by it.
+func readWindowSize(r io.Reader, max uint32) (uint32, error) {
+	count, err := readUint32(r)
+	if err != nil {
+		return 0, err
+	}
+	if count > max {
+		return 0, ErrWindowTooLarge
+	}
+	return count, nil
+}
+
+func writeACK(w io.Writer, version byte, seq uint32) error {
+	var buf bytes.Buffer
+	buf.WriteByte(version)
+	buf.WriteByte(protocol.CodeACK)
+	_ = binary.Write(&buf, binary.BigEndian, seq)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (s *Server) setReadDeadline(conn net.Conn) error {
+	return conn.SetReadDeadline(time.Now().Add(s.opts.timeout))
+}
+
+func (s *Server) setWriteDeadline(conn net.Conn) error {
+	return conn.SetWriteDeadline(time.Now().Add(s.opts.timeout))
+}
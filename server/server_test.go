@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/urso/go-lumber/v2/protocol"
+)
+
+func newTestServer(t *testing.T, opts ...Option) *Server {
+	t.Helper()
+
+	o, err := applyOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{
+		opts: o,
+		ch:   make(chan *Batch),
+		err:  make(chan error, 1),
+	}
+}
+
+func writeU32(w io.Writer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	_, _ = w.Write(b[:])
+}
+
+func writeWindow(w io.Writer, version byte, count uint32) {
+	_, _ = w.Write([]byte{version, protocol.CodeWindowSize})
+	writeU32(w, count)
+}
+
+func writeJSONFrame(w io.Writer, version byte, seq uint32, payload []byte) {
+	_, _ = w.Write([]byte{version, protocol.CodeJSONDataFrame})
+	writeU32(w, seq)
+	writeU32(w, uint32(len(payload)))
+	_, _ = w.Write(payload)
+}
+
+func writeString(w io.Writer, s string) {
+	writeU32(w, uint32(len(s)))
+	_, _ = w.Write([]byte(s))
+}
+
+func writeDataFrame(w io.Writer, seq uint32, pairs map[string]string) {
+	_, _ = w.Write([]byte{protocol.CodeVersion1, protocol.CodeDataFrame})
+	writeU32(w, seq)
+	writeU32(w, uint32(len(pairs)))
+	for k, v := range pairs {
+		writeString(w, k)
+		writeString(w, v)
+	}
+}
+
+// writeCompressedFrame zlib-compresses payload (raw frame bytes, as would
+// be written by writeJSONFrame/writeDataFrame) and writes it as a single
+// CodeCompressed frame.
+func writeCompressedFrame(w io.Writer, version byte, payload []byte) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write(payload)
+	_ = zw.Close()
+
+	_, _ = w.Write([]byte{version, protocol.CodeCompressed})
+	writeU32(w, uint32(buf.Len()))
+	_, _ = w.Write(buf.Bytes())
+}
+
+func readACK(r io.Reader) (byte, uint32, error) {
+	var b [6]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, 0, err
+	}
+	return b[1], binary.BigEndian.Uint32(b[2:]), nil
+}
+
+func TestHandleConnJSONWindow(t *testing.T) {
+	client, srv := net.Pipe()
+	defer client.Close()
+
+	s := newTestServer(t)
+	go s.handleConn(srv)
+
+	writeWindow(client, protocol.CodeVersion, 2)
+	writeJSONFrame(client, protocol.CodeVersion, 0, []byte(`{"a":1}`))
+	writeJSONFrame(client, protocol.CodeVersion, 1, []byte(`{"a":2}`))
+
+	batch := <-s.ch
+	if len(batch.Events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(batch.Events))
+	}
+	batch.ACK()
+
+	code, seq, err := readACK(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != protocol.CodeACK || seq != 2 {
+		t.Fatalf("expected final ack seq=2, got code=%c seq=%d", code, seq)
+	}
+}
+
+// TestHandleConnV1DataFrame verifies the server auto-detects the v1
+// framing from the window's version byte.
+func TestHandleConnV1DataFrame(t *testing.T) {
+	client, srv := net.Pipe()
+	defer client.Close()
+
+	s := newTestServer(t)
+	go s.handleConn(srv)
+
+	writeWindow(client, protocol.CodeVersion1, 1)
+	writeDataFrame(client, 0, map[string]string{"line": "hello"})
+
+	batch := <-s.ch
+	ev, ok := batch.Events[0].(map[string]string)
+	if !ok || ev["line"] != "hello" {
+		t.Fatalf("unexpected event: %#v", batch.Events[0])
+	}
+	batch.ACK()
+
+	if _, seq, err := readACK(client); err != nil || seq != 1 {
+		t.Fatalf("expected final ack seq=1, got seq=%d err=%v", seq, err)
+	}
+}
+
+func TestHandleConnPartialACK(t *testing.T) {
+	client, srv := net.Pipe()
+	defer client.Close()
+
+	s := newTestServer(t)
+	go s.handleConn(srv)
+
+	writeWindow(client, protocol.CodeVersion, 2)
+	writeJSONFrame(client, protocol.CodeVersion, 0, []byte(`{}`))
+	writeJSONFrame(client, protocol.CodeVersion, 1, []byte(`{}`))
+
+	batch := <-s.ch
+	batch.ACKEvents(1)
+
+	if _, seq, err := readACK(client); err != nil || seq != 1 {
+		t.Fatalf("expected partial ack seq=1, got seq=%d err=%v", seq, err)
+	}
+
+	batch.ACK()
+
+	if _, seq, err := readACK(client); err != nil || seq != 2 {
+		t.Fatalf("expected final ack seq=2, got seq=%d err=%v", seq, err)
+	}
+}
+
+func TestHandleConnWindowTooLarge(t *testing.T) {
+	client, srv := net.Pipe()
+	defer client.Close()
+
+	s := newTestServer(t, MaxWindowSize(1))
+
+	done := make(chan struct{})
+	go func() {
+		s.handleConn(srv)
+		close(done)
+	}()
+
+	writeWindow(client, protocol.CodeVersion, 2)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handleConn did not close the connection for an oversized window")
+	}
+}
+
+func TestHandleConnFrameTooLarge(t *testing.T) {
+	client, srv := net.Pipe()
+	defer client.Close()
+
+	s := newTestServer(t, MaxPayloadSize(4))
+
+	done := make(chan struct{})
+	go func() {
+		s.handleConn(srv)
+		close(done)
+	}()
+
+	writeWindow(client, protocol.CodeVersion, 1)
+	writeJSONFrame(client, protocol.CodeVersion, 0, []byte(`{"too":"big"}`))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handleConn did not close the connection for an oversized frame")
+	}
+}
+
+// TestHandleConnNestedCompressedFrame verifies that a CodeCompressed frame
+// nested inside another one is rejected outright rather than recursed
+// into, since recursing would let a client force arbitrarily many
+// allocations out of a tiny amount of wire data (each nesting level
+// allocates a fresh events slice sized to the whole remaining window).
+func TestHandleConnNestedCompressedFrame(t *testing.T) {
+	client, srv := net.Pipe()
+	defer client.Close()
+
+	s := newTestServer(t)
+
+	done := make(chan struct{})
+	go func() {
+		s.handleConn(srv)
+		close(done)
+	}()
+
+	var inner bytes.Buffer
+	writeJSONFrame(&inner, protocol.CodeVersion, 0, []byte(`{}`))
+	var nested bytes.Buffer
+	writeCompressedFrame(&nested, protocol.CodeVersion, inner.Bytes())
+
+	writeWindow(client, protocol.CodeVersion, 1)
+	writeCompressedFrame(client, protocol.CodeVersion, nested.Bytes())
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handleConn did not close the connection for a nested compressed frame")
+	}
+}
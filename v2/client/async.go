@@ -0,0 +1,125 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrAsyncNotEnabled is returned by AsyncSend if the client was not
+// constructed with the Async option.
+var ErrAsyncNotEnabled = errors.New("client not configured for async send")
+
+// asyncState tracks batches sent via AsyncSend that are awaiting their ACK.
+// A dedicated goroutine (run) reads ACKs off the connection and resolves
+// the oldest outstanding batch as it gets fully acknowledged, so the
+// caller can keep sending further windows without blocking on AwaitACK.
+type asyncState struct {
+	mu      sync.Mutex
+	pending []*pendingBatch
+}
+
+type pendingBatch struct {
+	count uint32
+	cb    func(seq uint32, err error)
+}
+
+func (c *Client) startAsync() {
+	c.async = &asyncState{}
+	go c.run()
+}
+
+// AsyncSend sends data and registers cb to be called once the batch has
+// been fully ACKed by the server (or the connection failed before that
+// happened). It does not block waiting for the ACK. The client must have
+// been created with the Async option.
+//
+// The write to the connection and the enqueue of the tracking entry run()
+// matches ACKs against are done under the same sendMu critical section,
+// so that concurrent AsyncSend callers can never have their writes land
+// on the wire in a different order than their entries land in the queue
+// (which would otherwise make run() match an ACK to the wrong entry).
+func (c *Client) AsyncSend(data []interface{}, cb func(seq uint32, err error)) error {
+	if c.async == nil {
+		return ErrAsyncNotEnabled
+	}
+	if len(data) == 0 {
+		if cb != nil {
+			cb(0, nil)
+		}
+		return nil
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if _, err := c.sendLocked(data); err != nil {
+		return err
+	}
+
+	c.async.mu.Lock()
+	c.async.pending = append(c.async.pending, &pendingBatch{
+		count: uint32(len(data)),
+		cb:    cb,
+	})
+	c.async.mu.Unlock()
+	return nil
+}
+
+// run reads ACKs off the connection and dispatches callbacks for batches
+// sent via AsyncSend as they become fully acknowledged. It runs until
+// ReceiveACK fails, at which point all batches still outstanding are
+// failed with the error that stopped the loop.
+func (c *Client) run() {
+	for {
+		seq, err := c.ReceiveACK()
+		if err != nil {
+			c.failPending(err)
+			return
+		}
+
+		c.async.mu.Lock()
+		if len(c.async.pending) == 0 {
+			c.async.mu.Unlock()
+			continue
+		}
+
+		head := c.async.pending[0]
+		if seq < head.count {
+			// partial ACK for the oldest outstanding batch, keep waiting
+			c.async.mu.Unlock()
+			continue
+		}
+
+		c.async.pending = c.async.pending[1:]
+		c.async.mu.Unlock()
+
+		if seq > head.count {
+			// A conforming server never ACKs past the count it was sent;
+			// treat this the same as awaitACK does for the sync path
+			// rather than reporting it as a clean completion.
+			err := fmt.Errorf("invalid sequence number received (seq=%v, expected=%v)", seq, head.count)
+			if head.cb != nil {
+				head.cb(head.count, err)
+			}
+			continue
+		}
+
+		if head.cb != nil {
+			head.cb(seq, nil)
+		}
+	}
+}
+
+func (c *Client) failPending(err error) {
+	c.async.mu.Lock()
+	pending := c.async.pending
+	c.async.pending = nil
+	c.async.mu.Unlock()
+
+	for _, p := range pending {
+		if p.cb != nil {
+			p.cb(0, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingConn is a net.Conn whose writes are appended to an in-memory
+// buffer (protected by a mutex, since concurrent AsyncSend callers may
+// write from different goroutines) and whose reads block until the test
+// closes it.
+type recordingConn struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed chan struct{}
+}
+
+func newRecordingConn() *recordingConn {
+	return &recordingConn{closed: make(chan struct{})}
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	<-c.closed
+	return 0, net.ErrClosed
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	runtime.Gosched() // encourage goroutines to interleave their writes
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(b)
+}
+
+func (c *recordingConn) bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.buf.Bytes()...)
+}
+
+func (c *recordingConn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+func (c *recordingConn) LocalAddr() net.Addr                { return nil }
+func (c *recordingConn) RemoteAddr() net.Addr               { return nil }
+func (c *recordingConn) SetDeadline(t time.Time) error      { return nil }
+func (c *recordingConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *recordingConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// parseWindowCounts walks a stream of uncompressed, unstreamed v2 windows
+// (as written by Send with no CompressionLevel/StreamCompress options) and
+// returns the announced event count of each window, in wire order.
+func parseWindowCounts(data []byte) []uint32 {
+	var counts []uint32
+	i := 0
+	for i < len(data) {
+		i += 2 // version, code ('W')
+		count := binary.BigEndian.Uint32(data[i:])
+		i += 4
+		counts = append(counts, count)
+
+		for n := uint32(0); n < count; n++ {
+			i += 2 // version, code ('J')
+			i += 4 // seq
+			ln := binary.BigEndian.Uint32(data[i:])
+			i += 4
+			i += int(ln)
+		}
+	}
+	return counts
+}
+
+// TestAsyncSendOrdering exercises many goroutines calling AsyncSend
+// concurrently and checks that the order batches land in the pending
+// queue (which run matches ACKs against) always matches the order their
+// windows were actually written to the wire.
+func TestAsyncSendOrdering(t *testing.T) {
+	conn := newRecordingConn()
+	defer conn.Close()
+
+	c, err := NewWithConn(conn, Async())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.AsyncSend(benchData(i+1), nil); err != nil {
+				t.Errorf("AsyncSend: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	wireCounts := parseWindowCounts(conn.bytes())
+
+	c.async.mu.Lock()
+	queueCounts := make([]uint32, len(c.async.pending))
+	for i, p := range c.async.pending {
+		queueCounts[i] = p.count
+	}
+	c.async.mu.Unlock()
+
+	if len(wireCounts) != n || len(queueCounts) != n {
+		t.Fatalf("expected %d windows, got %d on wire and %d queued", n, len(wireCounts), len(queueCounts))
+	}
+	for i := range wireCounts {
+		if wireCounts[i] != queueCounts[i] {
+			t.Fatalf("queue order diverges from wire order at index %d: wire=%v queue=%v", i, wireCounts, queueCounts)
+		}
+	}
+}
+
+// TestAsyncSendOverAck verifies that an ACK sequence beyond the oldest
+// outstanding batch's count is failed to the callback instead of being
+// treated as a successful completion, matching awaitACK's handling of the
+// same case on the synchronous path.
+func TestAsyncSendOverAck(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	c, err := NewWithConn(clientConn, Async())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	cbErr := make(chan error, 1)
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- c.AsyncSend(benchData(2), func(seq uint32, err error) {
+			cbErr <- err
+		})
+	}()
+
+	count, err := readRawWindow(serverConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := drainRawFrames(serverConn, count); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeRawACK(serverConn, count+1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-sendErr; err != nil {
+		t.Fatalf("AsyncSend: %v", err)
+	}
+
+	select {
+	case err := <-cbErr:
+		if err == nil {
+			t.Fatal("expected an error for an ACK sequence past the batch count, got nil")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("callback was not invoked for the over-ack")
+	}
+}
@@ -3,39 +3,71 @@ package client
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"sync"
 	"time"
 
+	opentracing "github.com/opentracing/opentracing-go"
+	otelTrace "go.opentelemetry.io/otel/trace"
+
 	"github.com/urso/go-lumber/v2/protocol"
 )
 
 type Client struct {
-	conn net.Conn
-	wb   *bytes.Buffer
+	conn   net.Conn
+	wb     *bytes.Buffer
+	cbuf   *bytes.Buffer
+	sendMu sync.Mutex
 
-	opts options
+	opts  options
+	async *asyncState
 }
 
 type options struct {
 	timeout     time.Duration
-	encoder     jsonEncoder
+	codec       Codec
 	compressLvl int
+	async       bool
+	streaming   bool
+	chunkSize   int
+	tracer      opentracing.Tracer
+	otelTracer  otelTrace.Tracer
 }
 
 type jsonEncoder func(interface{}) ([]byte, error)
 
+// Codec determines how event values are serialized onto the wire and
+// which data frame type byte identifies the result to the server. The
+// default Codec reproduces the original JSON wire format.
+type Codec interface {
+	// Encode serializes v into its wire representation.
+	Encode(v interface{}) ([]byte, error)
+
+	// ContentCode returns the data frame type byte identifying this
+	// codec's payload to the server.
+	ContentCode() byte
+}
+
+// jsonCodec is the default Codec, writing JSON data frames.
+type jsonCodec struct {
+	encode jsonEncoder
+}
+
+func (c jsonCodec) Encode(v interface{}) ([]byte, error) { return c.encode(v) }
+func (c jsonCodec) ContentCode() byte                    { return protocol.CodeJSONDataFrame }
+
 // Option type to be passed to New/Dial functions.
 type Option func(*options) error
 
 var (
-	codeWindowSize    = []byte{protocol.CodeVersion, protocol.CodeWindowSize}
-	codeCompressed    = []byte{protocol.CodeVersion, protocol.CodeCompressed}
-	codeJSONDataFrame = []byte{protocol.CodeVersion, protocol.CodeJSONDataFrame}
+	codeWindowSize = []byte{protocol.CodeVersion, protocol.CodeWindowSize}
+	codeCompressed = []byte{protocol.CodeVersion, protocol.CodeCompressed}
 
 	empty4 = []byte{0, 0, 0, 0}
 )
@@ -50,7 +82,18 @@ var (
 // to json.
 func JSONEncoder(encoder func(interface{}) ([]byte, error)) Option {
 	return func(opt *options) error {
-		opt.encoder = encoder
+		opt.codec = jsonCodec{encode: encoder}
+		return nil
+	}
+}
+
+// WithCodec client option configuring the Codec used to serialize events,
+// replacing the default JSON wire format (for example with CBOR,
+// MessagePack or Protobuf). The server must support the codec's
+// ContentCode to make sense of the resulting data frames.
+func WithCodec(codec Codec) Option {
+	return func(opt *options) error {
+		opt.codec = codec
 		return nil
 	}
 }
@@ -66,6 +109,16 @@ func Timeout(to time.Duration) Option {
 	}
 }
 
+// Async client option enabling the asynchronous pipelined send API
+// (AsyncSend). When enabled, the client starts a background goroutine
+// reading ACKs from the connection as soon as it is created.
+func Async() Option {
+	return func(opt *options) error {
+		opt.async = true
+		return nil
+	}
+}
+
 // CompressionLevel client option setting the compression level (0 to 9)
 func CompressionLevel(l int) Option {
 	return func(opt *options) error {
@@ -77,10 +130,58 @@ func CompressionLevel(l int) Option {
 	}
 }
 
+// StreamCompress client option enabling chunked streaming of compressed
+// payloads: instead of buffering the whole compressed window before
+// writing it, data is compressed and written to the connection in
+// independently framed chunks of ChunkSize events, so large windows never
+// need to be held in memory in full. Only effective together with
+// CompressionLevel.
+func StreamCompress() Option {
+	return func(opt *options) error {
+		opt.streaming = true
+		return nil
+	}
+}
+
+// ChunkSize sets the number of events compressed into each chunk when
+// StreamCompress is enabled. Defaults to 32.
+func ChunkSize(n int) Option {
+	return func(opt *options) error {
+		if n <= 0 {
+			return errors.New("chunk size must be positive")
+		}
+		opt.chunkSize = n
+		return nil
+	}
+}
+
+// Tracer client option configuring an opentracing.Tracer used to create a
+// span around every Send/SyncSend/AwaitACK call, tagged with batch size,
+// compression level, bytes written and ACK sequence. Errors are recorded
+// on the span. May be combined with OTelTracer to emit to both backends.
+func Tracer(t opentracing.Tracer) Option {
+	return func(opt *options) error {
+		opt.tracer = t
+		return nil
+	}
+}
+
+// OTelTracer client option configuring an OpenTelemetry trace.Tracer used
+// to create a span around every Send/SyncSend/AwaitACK call, tagged the
+// same way as Tracer. May be combined with Tracer to emit to both
+// backends.
+func OTelTracer(t otelTrace.Tracer) Option {
+	return func(opt *options) error {
+		opt.otelTracer = t
+		return nil
+	}
+}
+
 func applyOptions(opts []Option) (options, error) {
 	o := options{
-		encoder: json.Marshal,
-		timeout: 30 * time.Second,
+		codec:     jsonCodec{encode: json.Marshal},
+		timeout:   30 * time.Second,
+		chunkSize: 32,
 	}
 
 	for _, opt := range opts {
@@ -97,11 +198,16 @@ func NewWithConn(c net.Conn, opts ...Option) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{
+	client := &Client{
 		conn: c,
 		wb:   bytes.NewBuffer(nil),
+		cbuf: bytes.NewBuffer(nil),
 		opts: o,
-	}, nil
+	}
+	if o.async {
+		client.startAsync()
+	}
+	return client, nil
 }
 
 // Dial up to lumberjack server and return new Client. Returns error
@@ -141,19 +247,76 @@ func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
+// SyncSend sends data and waits for it to be fully ACKed. Equivalent to
+// SyncSendContext(context.Background(), data).
 func (c *Client) SyncSend(data []interface{}) (int, error) {
-	if err := c.Send(data); err != nil {
+	return c.SyncSendContext(context.Background(), data)
+}
+
+// SyncSendContext sends data and waits for it to be fully ACKed, tracing
+// Send and AwaitACK as children of a span wrapping both (and of any span
+// already carried by ctx), so a caller-supplied ctx threads end-to-end
+// across a log-shipping pipeline.
+func (c *Client) SyncSendContext(ctx context.Context, data []interface{}) (int, error) {
+	ctx, s := c.startSpan(ctx, "lumberjack.SyncSend")
+	defer s.finish()
+	s.setTag("lumberjack.batch_size", len(data))
+
+	if err := c.SendContext(ctx, data); err != nil {
+		s.recordError(err)
 		return 0, err
 	}
 
-	seq, err := c.AwaitACK(uint32(len(data)))
+	seq, err := c.AwaitACKContext(ctx, uint32(len(data)))
+	if err != nil {
+		s.recordError(err)
+	}
 	return int(seq), err
 }
 
-// Send sends all data without waiting for ACK
+// Send sends all data without waiting for ACK. Equivalent to
+// SendContext with a background context.
 func (c *Client) Send(data []interface{}) error {
+	return c.SendContext(context.Background(), data)
+}
+
+// SendContext sends all data without waiting for ACK, tracing the call as
+// a child of any span already carried by ctx.
+func (c *Client) SendContext(ctx context.Context, data []interface{}) error {
+	_, s := c.startSpan(ctx, "lumberjack.Send")
+	defer s.finish()
+	s.setTag("lumberjack.batch_size", len(data))
+	s.setTag("lumberjack.compression_level", c.opts.compressLvl)
+
+	n, err := c.send(data)
+	if err != nil {
+		s.recordError(err)
+		return err
+	}
+
+	s.setTag("lumberjack.bytes_written", n)
+	return nil
+}
+
+// send writes data to the connection and returns the number of bytes
+// written to it.
+func (c *Client) send(data []interface{}) (int, error) {
 	if len(data) == 0 {
-		return nil
+		return 0, nil
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.sendLocked(data)
+}
+
+// sendLocked does the actual work of send. Callers must hold sendMu, and
+// must keep holding it for as long as anything needs to observe the write
+// in the same order it happened on the wire (AsyncSend enqueues its
+// tracking entry while still holding sendMu for exactly this reason).
+func (c *Client) sendLocked(data []interface{}) (int, error) {
+	if c.opts.compressLvl > 0 && c.opts.streaming {
+		return c.sendStreaming(data)
 	}
 
 	// 1. create window message
@@ -175,43 +338,112 @@ func (c *Client) Send(data []interface{}) error {
 		_, _ = c.wb.Write(empty4)
 		offPayload := c.wb.Len()
 
-		// compress payload
-		w, err := zlib.NewWriterLevel(c.wb, c.opts.compressLvl)
-		if err != nil {
-			return err
-		}
+		// compress payload, reusing a pooled zlib.Writer for this level
+		w := getZlibWriter(c.opts.compressLvl, c.wb)
 
-		if err := c.serialize(w, data); err != nil {
-			return err
+		if err := c.serialize(w, data, 0); err != nil {
+			return 0, err
 		}
 
 		if err := w.Close(); err != nil {
-			return err
+			return 0, err
 		}
+		putZlibWriter(c.opts.compressLvl, w)
 
 		// write compress header
 		payloadSz := c.wb.Len() - offPayload
 		binary.BigEndian.PutUint32(c.wb.Bytes()[offSz:], uint32(payloadSz))
 	} else {
-		if err := c.serialize(c.wb, data); err != nil {
-			return err
+		if err := c.serialize(c.wb, data, 0); err != nil {
+			return 0, err
 		}
 	}
 
 	// 3. send buffer
 	if err := c.setWriteDeadline(); err != nil {
-		return nil
+		return 0, nil
 	}
 	payload := c.wb.Bytes()
+	total := len(payload)
 	for len(payload) > 0 {
 		n, err := c.conn.Write(payload)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		payload = payload[n:]
 	}
 
+	return total, nil
+}
+
+// sendStreaming sends data compressed in independently framed chunks of
+// c.opts.chunkSize events each, writing every chunk to the connection as
+// soon as it is compressed instead of buffering the whole window. Returns
+// the total number of bytes written to the connection.
+func (c *Client) sendStreaming(data []interface{}) (int, error) {
+	if err := c.setWriteDeadline(); err != nil {
+		return 0, err
+	}
+
+	c.wb.Reset()
+	_, _ = c.wb.Write(codeWindowSize)
+	writeUint32(c.wb, uint32(len(data)))
+	if err := c.writeAll(c.wb.Bytes()); err != nil {
+		return 0, err
+	}
+	total := c.wb.Len()
+
+	for start := 0; start < len(data); start += c.opts.chunkSize {
+		end := start + c.opts.chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		n, err := c.sendChunk(data[start:end], start)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+
+	return total, nil
+}
+
+// sendChunk compresses and writes chunk to the connection as a single
+// compressed frame and returns the number of bytes written.
+func (c *Client) sendChunk(chunk []interface{}, offset int) (int, error) {
+	c.cbuf.Reset()
+
+	w := getZlibWriter(c.opts.compressLvl, c.cbuf)
+	if err := c.serialize(w, chunk, offset); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	putZlibWriter(c.opts.compressLvl, w)
+
+	c.wb.Reset()
+	_, _ = c.wb.Write(codeCompressed)
+	writeUint32(c.wb, uint32(c.cbuf.Len()))
+	if err := c.writeAll(c.wb.Bytes()); err != nil {
+		return 0, err
+	}
+	if err := c.writeAll(c.cbuf.Bytes()); err != nil {
+		return 0, err
+	}
+	return c.wb.Len() + c.cbuf.Len(), nil
+}
+
+func (c *Client) writeAll(b []byte) error {
+	for len(b) > 0 {
+		n, err := c.conn.Write(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
 	return nil
 }
 
@@ -243,8 +475,29 @@ func (c *Client) ReceiveACK() (uint32, error) {
 	return seq, nil
 }
 
-// AwaitACK waits for count elements being ACKed. Returns last known ACK on error.
+// AwaitACK waits for count elements being ACKed. Equivalent to
+// AwaitACKContext(context.Background(), count).
 func (c *Client) AwaitACK(count uint32) (uint32, error) {
+	return c.AwaitACKContext(context.Background(), count)
+}
+
+// AwaitACKContext waits for count elements being ACKed, tracing the call
+// as a child of any span already carried by ctx. Returns last known ACK
+// on error.
+func (c *Client) AwaitACKContext(ctx context.Context, count uint32) (uint32, error) {
+	_, s := c.startSpan(ctx, "lumberjack.AwaitACK")
+	defer s.finish()
+	s.setTag("lumberjack.ack_count", count)
+
+	ackSeq, err := c.awaitACK(count)
+	s.setTag("lumberjack.ack_seq", ackSeq)
+	if err != nil {
+		s.recordError(err)
+	}
+	return ackSeq, err
+}
+
+func (c *Client) awaitACK(count uint32) (uint32, error) {
 	var ackSeq uint32
 	var err error
 
@@ -263,28 +516,59 @@ func (c *Client) AwaitACK(count uint32) (uint32, error) {
 	return ackSeq, nil
 }
 
-func (c *Client) serialize(out io.Writer, data []interface{}) error {
+// serialize writes data as a sequence of data frames using the client's
+// Codec, numbering each event's seq field starting at offset (the
+// event's absolute position within the overall send window).
+func (c *Client) serialize(out io.Writer, data []interface{}, offset int) error {
+	frameHeader := []byte{protocol.CodeVersion, c.opts.codec.ContentCode()}
+
 	for i, d := range data {
-		b, err := c.opts.encoder(d)
+		b, err := c.opts.codec.Encode(d)
 		if err != nil {
 			return err
 		}
 
-		// Write JSON Data Frame:
+		// Write Data Frame:
 		// version: uint8 = '2'
-		// code: uint8 = 'J'
+		// code: uint8 = codec.ContentCode()
 		// seq: uint32
 		// payloadLen (bytes): uint32
-		// payload: JSON document
+		// payload: codec encoded document
 
-		_, _ = out.Write(codeJSONDataFrame)
-		writeUint32(out, uint32(i))
+		_, _ = out.Write(frameHeader)
+		writeUint32(out, uint32(offset+i))
 		writeUint32(out, uint32(len(b)))
 		_, _ = out.Write(b)
 	}
 	return nil
 }
 
+// zlibWriterPools holds a sync.Pool of reusable *zlib.Writer per
+// compression level (indices 1 to 9; index 0 is unused since level 0 is
+// never compressed), avoiding an allocation for every compressed Send.
+var zlibWriterPools [10]sync.Pool
+
+// getZlibWriter returns a *zlib.Writer for level writing to w, reusing a
+// pooled writer via Reset when one is available.
+func getZlibWriter(level int, w io.Writer) *zlib.Writer {
+	if v := zlibWriterPools[level].Get(); v != nil {
+		zw := v.(*zlib.Writer)
+		zw.Reset(w)
+		return zw
+	}
+
+	// NewWriterLevel only returns an error for an invalid level, which
+	// CompressionLevel already validates against.
+	zw, _ := zlib.NewWriterLevel(w, level)
+	return zw
+}
+
+// putZlibWriter returns zw to the pool for level, to be reused by a later
+// getZlibWriter call. zw must already be closed.
+func putZlibWriter(level int, zw *zlib.Writer) {
+	zlibWriterPools[level].Put(zw)
+}
+
 func (c *Client) setWriteDeadline() error {
 	return c.conn.SetWriteDeadline(time.Now().Add(c.opts.timeout))
 }
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// discardConn is a net.Conn whose writes are dropped and whose reads block
+// until the benchmark closes it, so Send can be measured without a real
+// server on the other end.
+type discardConn struct {
+	closed chan struct{}
+}
+
+func newDiscardConn() *discardConn {
+	return &discardConn{closed: make(chan struct{})}
+}
+
+func (c *discardConn) Read(b []byte) (int, error) {
+	<-c.closed
+	return 0, net.ErrClosed
+}
+
+func (c *discardConn) Write(b []byte) (int, error) { return len(b), nil }
+func (c *discardConn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+func (c *discardConn) LocalAddr() net.Addr                { return nil }
+func (c *discardConn) RemoteAddr() net.Addr               { return nil }
+func (c *discardConn) SetDeadline(t time.Time) error      { return nil }
+func (c *discardConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *discardConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func benchData(n int) []interface{} {
+	data := make([]interface{}, n)
+	for i := range data {
+		data[i] = map[string]interface{}{
+			"message": "the quick brown fox jumps over the lazy dog",
+			"seq":     i,
+		}
+	}
+	return data
+}
+
+func BenchmarkSend(b *testing.B) {
+	conn := newDiscardConn()
+	defer conn.Close()
+
+	c, err := NewWithConn(conn)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data := benchData(64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Send(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSendCompressed(b *testing.B) {
+	conn := newDiscardConn()
+	defer conn.Close()
+
+	c, err := NewWithConn(conn, CompressionLevel(3))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data := benchData(64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Send(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSendStreamingCompressed(b *testing.B) {
+	conn := newDiscardConn()
+	defer conn.Close()
+
+	c, err := NewWithConn(conn, CompressionLevel(3), StreamCompress(), ChunkSize(16))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data := benchData(64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Send(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ClientFactory dials a fresh connection to the lumberjack server, the same
+// way Dial or DialWith would. It is called by ReliableClient every time a
+// (re)connect is required.
+type ClientFactory func() (*Client, error)
+
+// ReliableClient wraps a ClientFactory with automatic reconnect, backoff
+// and resend of windows that were sent but not yet fully ACKed when the
+// connection dropped.
+type ReliableClient struct {
+	dial ClientFactory
+	opts reliableOptions
+
+	mu   sync.Mutex // serializes Send/SendContext calls and guards ring
+	ring *ring
+
+	connMu sync.Mutex // guards client, independent of mu so Close interrupts a blocked reconnect
+	client *Client
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+type reliableOptions struct {
+	backoffInit time.Duration
+	backoffMax  time.Duration
+	jitter      float64
+	maxPending  int
+}
+
+// ReliableOption type to be passed to NewReliableClient.
+type ReliableOption func(*reliableOptions) error
+
+// ErrTooManyPending is returned by Send/SendContext if more windows are
+// in flight than the configured pending window limit allows.
+var ErrTooManyPending = errors.New("too many pending (unacked) windows")
+
+// ErrClosed is returned by Send/SendContext if the ReliableClient was
+// closed while sending or reconnecting.
+var ErrClosed = errors.New("reliable client closed")
+
+// BackoffInit sets the initial delay used between reconnect attempts.
+func BackoffInit(d time.Duration) ReliableOption {
+	return func(opt *reliableOptions) error {
+		if d <= 0 {
+			return errors.New("initial backoff must be positive")
+		}
+		opt.backoffInit = d
+		return nil
+	}
+}
+
+// BackoffMax sets the maximum delay used between reconnect attempts.
+func BackoffMax(d time.Duration) ReliableOption {
+	return func(opt *reliableOptions) error {
+		if d <= 0 {
+			return errors.New("max backoff must be positive")
+		}
+		opt.backoffMax = d
+		return nil
+	}
+}
+
+// BackoffJitter sets the fraction (0 to 1) of random jitter added to each
+// backoff delay, to avoid thundering-herd reconnects.
+func BackoffJitter(jitter float64) ReliableOption {
+	return func(opt *reliableOptions) error {
+		if !(0 <= jitter && jitter <= 1) {
+			return errors.New("jitter must be within 0 and 1")
+		}
+		opt.jitter = jitter
+		return nil
+	}
+}
+
+// PendingWindows sets how many un-ACKed windows may be queued for resend
+// before Send/SendContext starts rejecting new ones.
+func PendingWindows(n int) ReliableOption {
+	return func(opt *reliableOptions) error {
+		if n <= 0 {
+			return errors.New("pending window limit must be positive")
+		}
+		opt.maxPending = n
+		return nil
+	}
+}
+
+func applyReliableOptions(opts []ReliableOption) (reliableOptions, error) {
+	o := reliableOptions{
+		backoffInit: 1 * time.Second,
+		backoffMax:  1 * time.Minute,
+		jitter:      0.2,
+		maxPending:  16,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// NewReliableClient creates a ReliableClient dialing its first connection
+// via the given factory. Returns an error if the initial connect fails.
+func NewReliableClient(dial ClientFactory, opts ...ReliableOption) (*ReliableClient, error) {
+	o, err := applyReliableOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReliableClient{
+		dial:   dial,
+		opts:   o,
+		client: c,
+		ring:   newRing(o.maxPending),
+		closed: make(chan struct{}),
+	}, nil
+}
+
+// Close closes the current underlying connection and interrupts any
+// reconnect loop blocked in backoff, even one started by a SendContext
+// call whose ctx never completes (e.g. context.Background()). It does not
+// wait for that call to return.
+func (rc *ReliableClient) Close() error {
+	rc.closeOnce.Do(func() { close(rc.closed) })
+
+	rc.connMu.Lock()
+	defer rc.connMu.Unlock()
+	return rc.client.Close()
+}
+
+// currentClient returns the client currently in use, synchronized against
+// concurrent Close/reconnect swapping it out.
+func (rc *ReliableClient) currentClient() *Client {
+	rc.connMu.Lock()
+	defer rc.connMu.Unlock()
+	return rc.client
+}
+
+// Send sends data and waits for it to be fully ACKed, transparently
+// reconnecting and resending on connection failure. It is equivalent to
+// SendContext(context.Background(), data).
+func (rc *ReliableClient) Send(data []interface{}) (int, error) {
+	return rc.SendContext(context.Background(), data)
+}
+
+// SendContext sends data and waits for it to be fully ACKed, transparently
+// reconnecting and resending on connection failure. ctx is checked before
+// each send attempt and while waiting out a reconnect backoff, returning
+// ctx.Err() once it is done; a send or ACK wait already in progress is not
+// interrupted by ctx.
+func (rc *ReliableClient) SendContext(ctx context.Context, data []interface{}) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if !rc.ring.push(data) {
+		return 0, ErrTooManyPending
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-rc.closed:
+			return 0, ErrClosed
+		default:
+		}
+
+		if err := rc.replay(ctx); err == nil {
+			seq := rc.ring.frontLen()
+			rc.ring.popFront()
+			return seq, nil
+		}
+
+		if err := rc.reconnect(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// replay resends every window still queued in the ring, oldest first,
+// popping each but the newest as soon as it has been fully ACKed. The
+// newest window is left in the ring for the caller to pop.
+func (rc *ReliableClient) replay(ctx context.Context) error {
+	for rc.ring.len() > 1 {
+		w, _ := rc.ring.peekFront()
+		if err := rc.sendWindow(w); err != nil {
+			return err
+		}
+		rc.ring.popFront()
+	}
+
+	w, ok := rc.ring.peekFront()
+	if !ok {
+		return nil
+	}
+	return rc.sendWindow(w)
+}
+
+func (rc *ReliableClient) sendWindow(data []interface{}) error {
+	c := rc.currentClient()
+	if err := c.Send(data); err != nil {
+		return err
+	}
+	_, err := c.AwaitACK(uint32(len(data)))
+	return err
+}
+
+// reconnect replaces the current (presumably broken) client with a fresh
+// one obtained from the factory, retrying with exponential backoff and
+// jitter until it succeeds, ctx is done, or rc is Closed. The client
+// swap is done under connMu rather than mu, so a concurrent Close call
+// is never blocked behind a reconnect in backoff.
+func (rc *ReliableClient) reconnect(ctx context.Context) error {
+	rc.connMu.Lock()
+	_ = rc.client.Close() // ignore error, connection is already broken
+	rc.connMu.Unlock()
+
+	delay := rc.opts.backoffInit
+	for {
+		select {
+		case <-rc.closed:
+			return ErrClosed
+		default:
+		}
+
+		c, err := rc.dial()
+		if err == nil {
+			select {
+			case <-rc.closed:
+				_ = c.Close() // ignore error, rc was closed while we were dialing
+				return ErrClosed
+			default:
+			}
+
+			rc.connMu.Lock()
+			rc.client = c
+			rc.connMu.Unlock()
+			return nil
+		}
+
+		jittered := delay
+		if rc.opts.jitter > 0 {
+			jittered += time.Duration(rand.Float64() * rc.opts.jitter * float64(delay))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-rc.closed:
+			return ErrClosed
+		case <-time.After(jittered):
+		}
+
+		delay *= 2
+		if delay > rc.opts.backoffMax {
+			delay = rc.opts.backoffMax
+		}
+	}
+}
+
+// ring is a fixed capacity FIFO of not-yet-acknowledged send windows.
+type ring struct {
+	windows [][]interface{}
+	cap     int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{cap: capacity}
+}
+
+func (r *ring) push(w []interface{}) bool {
+	if len(r.windows) >= r.cap {
+		return false
+	}
+	r.windows = append(r.windows, w)
+	return true
+}
+
+func (r *ring) peekFront() ([]interface{}, bool) {
+	if len(r.windows) == 0 {
+		return nil, false
+	}
+	return r.windows[0], true
+}
+
+func (r *ring) popFront() {
+	if len(r.windows) == 0 {
+		return
+	}
+	r.windows = r.windows[1:]
+}
+
+func (r *ring) frontLen() int {
+	if len(r.windows) == 0 {
+		return 0
+	}
+	return len(r.windows[0])
+}
+
+func (r *ring) len() int {
+	return len(r.windows)
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/urso/go-lumber/v2/protocol"
+)
+
+func readRawWindow(r io.Reader) (uint32, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, err
+	}
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+// drainRawFrames reads and discards count v2 data frames from r.
+func drainRawFrames(r io.Reader, count uint32) error {
+	for i := uint32(0); i < count; i++ {
+		var hdr [2]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return err
+		}
+		var seqLen [8]byte
+		if _, err := io.ReadFull(r, seqLen[:]); err != nil {
+			return err
+		}
+		ln := binary.BigEndian.Uint32(seqLen[4:])
+		if _, err := io.ReadFull(r, make([]byte, ln)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRawACK(w io.Writer, seq uint32) error {
+	buf := make([]byte, 6)
+	buf[0] = protocol.CodeVersion
+	buf[1] = protocol.CodeACK
+	binary.BigEndian.PutUint32(buf[2:], seq)
+	_, err := w.Write(buf)
+	return err
+}
+
+// TestReliableClientResendAfterDrop verifies that a window dropped before
+// being ACKed (the connection failing mid-flight) is resent in full over
+// the reconnected connection.
+func TestReliableClientResendAfterDrop(t *testing.T) {
+	conns := make(chan net.Conn, 2)
+	dial := func() (*Client, error) {
+		clientConn, serverConn := net.Pipe()
+		conns <- serverConn
+		return NewWithConn(clientConn, Timeout(time.Second))
+	}
+
+	rc, err := NewReliableClient(dial, BackoffInit(5*time.Millisecond), BackoffMax(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	data := []interface{}{map[string]interface{}{"msg": "hello"}}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := rc.Send(data)
+		result <- err
+	}()
+
+	// First connection: read the window in full, then drop it without
+	// ACKing, simulating the connection failing mid-flight.
+	conn1 := <-conns
+	count, err := readRawWindow(conn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := drainRawFrames(conn1, count); err != nil {
+		t.Fatal(err)
+	}
+	conn1.Close()
+
+	// ReliableClient should reconnect and resend the very same window.
+	conn2 := <-conns
+	count2, err := readRawWindow(conn2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count2 != count {
+		t.Fatalf("resent window has count=%d, want %d", count2, count)
+	}
+	if err := drainRawFrames(conn2, count2); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeRawACK(conn2, count2); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Send returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Send did not return after the resent window was ACKed")
+	}
+}
+
+// TestReliableClientCloseInterruptsReconnect verifies that Close returns
+// promptly even while another goroutine's Send is stuck in reconnect's
+// backoff, instead of waiting out the (here, very long) backoff delay.
+func TestReliableClientCloseInterruptsReconnect(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+
+	first := true
+	dial := func() (*Client, error) {
+		if first {
+			first = false
+			return NewWithConn(clientConn)
+		}
+		return nil, errors.New("dial: server unreachable")
+	}
+
+	rc, err := NewReliableClient(dial, BackoffInit(time.Minute), BackoffMax(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn.Close() // break the only working connection
+
+	sendDone := make(chan error, 1)
+	go func() {
+		_, err := rc.Send([]interface{}{"x"})
+		sendDone <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond) // let Send hit the broken conn and enter backoff
+
+	closeDone := make(chan error, 1)
+	go func() { closeDone <- rc.Close() }()
+
+	select {
+	case err := <-closeDone:
+		if err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return promptly while reconnect was backing off")
+	}
+
+	select {
+	case err := <-sendDone:
+		if err != ErrClosed {
+			t.Fatalf("expected ErrClosed from Send, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send did not return after Close")
+	}
+}
+
+// TestReliableClientDialRacesClose verifies that a dial which completes
+// successfully just after a concurrent Close does not get installed as
+// the live connection, and that the now-unwanted connection it returned
+// is itself closed rather than leaked.
+func TestReliableClientDialRacesClose(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	first := true
+	dialing := make(chan struct{})
+	proceed := make(chan struct{})
+	second := newDiscardConn()
+
+	dial := func() (*Client, error) {
+		if first {
+			first = false
+			return NewWithConn(clientConn)
+		}
+		close(dialing)
+		<-proceed // block until the test has called Close
+		return NewWithConn(second)
+	}
+
+	rc, err := NewReliableClient(dial, BackoffInit(time.Millisecond), BackoffMax(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn.Close() // break the only working connection
+
+	sendDone := make(chan error, 1)
+	go func() {
+		_, err := rc.Send([]interface{}{"x"})
+		sendDone <- err
+	}()
+
+	select {
+	case <-dialing:
+	case <-time.After(time.Second):
+		t.Fatal("reconnect did not reach its second dial")
+	}
+
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	close(proceed) // let the second dial return now that rc is closed
+
+	select {
+	case err := <-sendDone:
+		if err != ErrClosed {
+			t.Fatalf("expected ErrClosed from Send, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send did not return after Close")
+	}
+
+	select {
+	case <-second.closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection dialed after Close was not closed")
+	}
+}
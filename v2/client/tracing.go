@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	otelTrace "go.opentelemetry.io/otel/trace"
+)
+
+// span is a minimal abstraction over whichever tracing backend was
+// configured with Tracer or OTelTracer, letting Send/SyncSend/AwaitACK
+// record tags and errors without caring which API is in use. Both fields
+// are nil, and every method a no-op, when no tracer was configured.
+type span struct {
+	ot   opentracing.Span
+	otel otelTrace.Span
+}
+
+// startSpan starts name as a child of any span already carried by ctx,
+// using whichever tracer(s) were configured on the client. The returned
+// context carries the new span(s) so a caller-supplied ctx threads
+// end-to-end across a log-shipping pipeline.
+func (c *Client) startSpan(ctx context.Context, name string) (context.Context, span) {
+	var s span
+
+	if c.opts.tracer != nil {
+		var opts []opentracing.StartSpanOption
+		if parent := opentracing.SpanFromContext(ctx); parent != nil {
+			opts = append(opts, opentracing.ChildOf(parent.Context()))
+		}
+		s.ot = c.opts.tracer.StartSpan(name, opts...)
+		ctx = opentracing.ContextWithSpan(ctx, s.ot)
+	}
+
+	if c.opts.otelTracer != nil {
+		ctx, s.otel = c.opts.otelTracer.Start(ctx, name)
+	}
+
+	return ctx, s
+}
+
+func (s span) setTag(key string, value interface{}) {
+	if s.ot != nil {
+		s.ot.SetTag(key, value)
+	}
+	if s.otel != nil {
+		s.otel.SetAttributes(attributeFor(key, value))
+	}
+}
+
+func (s span) recordError(err error) {
+	if err == nil {
+		return
+	}
+	if s.ot != nil {
+		s.ot.SetTag("error", true)
+		s.ot.LogFields(otlog.Error(err))
+	}
+	if s.otel != nil {
+		s.otel.RecordError(err)
+		s.otel.SetStatus(codes.Error, err.Error())
+	}
+}
+
+func (s span) finish() {
+	if s.ot != nil {
+		s.ot.Finish()
+	}
+	if s.otel != nil {
+		s.otel.End()
+	}
+}
+
+func attributeFor(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case uint32:
+		return attribute.Int64(key, int64(v))
+	case string:
+		return attribute.String(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestStartSpanOpenTracing(t *testing.T) {
+	tracer := mocktracer.New()
+	c := &Client{opts: options{tracer: tracer}}
+
+	ctx, parent := c.startSpan(context.Background(), "lumberjack.SyncSend")
+	parent.setTag("lumberjack.batch_size", 3)
+
+	_, child := c.startSpan(ctx, "lumberjack.Send")
+	child.setTag("lumberjack.bytes_written", 42)
+	child.recordError(errors.New("boom"))
+	child.finish()
+	parent.finish()
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 finished spans, got %d", len(spans))
+	}
+
+	var parentSpan, childSpan *mocktracer.MockSpan
+	for _, s := range spans {
+		switch s.OperationName {
+		case "lumberjack.SyncSend":
+			parentSpan = s
+		case "lumberjack.Send":
+			childSpan = s
+		}
+	}
+	if parentSpan == nil || childSpan == nil {
+		t.Fatalf("expected spans named lumberjack.SyncSend and lumberjack.Send, got %v", spans)
+	}
+	if childSpan.ParentID != parentSpan.SpanContext.SpanID {
+		t.Fatalf("Send span's parent ID = %d, want %d (not nested under SyncSend)", childSpan.ParentID, parentSpan.SpanContext.SpanID)
+	}
+	if got := parentSpan.Tag("lumberjack.batch_size"); got != 3 {
+		t.Fatalf("batch_size tag = %v, want 3", got)
+	}
+	if got := childSpan.Tag("lumberjack.bytes_written"); got != 42 {
+		t.Fatalf("bytes_written tag = %v, want 42", got)
+	}
+	if got := childSpan.Tag("error"); got != true {
+		t.Fatalf("error tag = %v, want true after recordError", got)
+	}
+}
+
+// fakeOtelSpan records what was set on it via the otelTrace.Span methods
+// startSpan/setTag/recordError/finish drive, embedding noop.Span so it
+// satisfies the interface without implementing every method.
+type fakeOtelSpan struct {
+	noop.Span
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	errs   []error
+	status codes.Code
+	ended  bool
+}
+
+func (s *fakeOtelSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[string(a.Key)] = a.Value.AsInterface()
+	}
+}
+
+func (s *fakeOtelSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *fakeOtelSpan) SetStatus(code codes.Code, _ string) {
+	s.status = code
+}
+
+func (s *fakeOtelSpan) End(...trace.SpanEndOption) {
+	s.ended = true
+}
+
+type fakeOtelSpanKey struct{}
+
+// fakeOtelTracer records every span it starts, nesting them based on
+// whatever span the passed-in ctx already carries, so a test can assert
+// startSpan actually threads ctx through to nest children under it.
+type fakeOtelTracer struct {
+	noop.Tracer
+	spans []*fakeOtelSpan
+}
+
+func (t *fakeOtelTracer) Start(ctx context.Context, name string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &fakeOtelSpan{name: name, attrs: map[string]interface{}{}}
+	if parent, ok := ctx.Value(fakeOtelSpanKey{}).(*fakeOtelSpan); ok {
+		s.parent = parent.name
+	}
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, fakeOtelSpanKey{}, s), s
+}
+
+func TestStartSpanOTel(t *testing.T) {
+	tracer := &fakeOtelTracer{}
+	c := &Client{opts: options{otelTracer: tracer}}
+
+	ctx, parent := c.startSpan(context.Background(), "lumberjack.SyncSend")
+	parent.setTag("lumberjack.batch_size", 3)
+
+	_, child := c.startSpan(ctx, "lumberjack.Send")
+	child.setTag("lumberjack.bytes_written", 42)
+	child.recordError(errors.New("boom"))
+	child.finish()
+	parent.finish()
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("expected 2 spans started, got %d", len(tracer.spans))
+	}
+	parentSpan, childSpan := tracer.spans[0], tracer.spans[1]
+	if childSpan.parent != parentSpan.name {
+		t.Fatalf("Send span's parent = %q, want %q (not nested under SyncSend)", childSpan.parent, parentSpan.name)
+	}
+	if got := parentSpan.attrs["lumberjack.batch_size"]; got != int64(3) {
+		t.Fatalf("batch_size attribute = %v, want 3", got)
+	}
+	if got := childSpan.attrs["lumberjack.bytes_written"]; got != int64(42) {
+		t.Fatalf("bytes_written attribute = %v, want 42", got)
+	}
+	if len(childSpan.errs) != 1 || childSpan.errs[0].Error() != "boom" {
+		t.Fatalf("expected recordError to record the error, got %v", childSpan.errs)
+	}
+	if childSpan.status != codes.Error {
+		t.Fatalf("status = %v, want codes.Error", childSpan.status)
+	}
+	if !parentSpan.ended || !childSpan.ended {
+		t.Fatal("expected finish() to End both spans")
+	}
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"io"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsConn adapts a *websocket.Conn into a net.Conn, letting the existing
+// Lumberjack framing code in Client run unchanged over a WebSocket
+// connection. Writes are sent as whole binary messages (so zlib
+// compressed payloads are not corrupted by a text-mode conversion) and
+// reads are served from the current message, requesting the next one
+// once it is exhausted. websocket.Conn already exposes
+// SetReadDeadline/SetWriteDeadline/Close/LocalAddr/RemoteAddr matching
+// net.Conn, so they are used unmodified via embedding.
+type wsConn struct {
+	*websocket.Conn
+	r io.Reader
+}
+
+func newWSConn(c *websocket.Conn) *wsConn {
+	return &wsConn{Conn: c}
+}
+
+func (c *wsConn) Read(b []byte) (int, error) {
+	for {
+		if c.r == nil {
+			_, r, err := c.Conn.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.r = r
+		}
+
+		n, err := c.r.Read(b)
+		if err == io.EOF {
+			c.r = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(b []byte) (int, error) {
+	if err := c.Conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.Conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// DialWebSocket connects to a Lumberjack server reachable over WebSocket
+// (ws:// or wss://) and returns a new Client using the same framing as a
+// plain TCP connection. This allows reaching servers such as Logstash
+// behind HTTP reverse proxies and load balancers that do not pass raw
+// TCP. Returns error if the WebSocket handshake fails.
+func DialWebSocket(url string, opts ...Option) (*Client, error) {
+	o, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: o.timeout}
+	wsc, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewWithConn(newWSConn(wsc), opts...)
+	if err != nil {
+		_ = wsc.Close() // ignore error
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestWSConnReadStitching verifies that wsConn.Read correctly stitches
+// together reads smaller than a message (served from the same
+// NextReader) and reads crossing a message boundary (served by fetching
+// the next NextReader), so the framing code above it sees one continuous
+// byte stream regardless of how the peer chunked its binary messages.
+func TestWSConnReadStitching(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	msg1 := []byte(strings.Repeat("a", 11))
+	msg2 := []byte(strings.Repeat("b", 5))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		if err := conn.WriteMessage(websocket.BinaryMessage, msg1); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, msg2); err != nil {
+			t.Error(err)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	c, err := DialWebSocket(wsURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	want := append(append([]byte{}, msg1...), msg2...)
+	got := make([]byte, 0, len(want))
+	buf := make([]byte, 4) // smaller than either message, forces a boundary crossing mid-read
+
+	for len(got) < len(want) {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		got = append(got, buf[:n]...)
+	}
+
+	if string(got) != string(want) {
+		t.Fatalf("stitched read = %q, want %q", got, want)
+	}
+}
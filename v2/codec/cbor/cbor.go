@@ -0,0 +1,27 @@
+// Package cbor implements a client.Codec serializing events as CBOR.
+package cbor
+
+import (
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/urso/go-lumber/v2/protocol"
+)
+
+// Codec encodes events as CBOR (RFC 8949), identifying itself to the
+// server with protocol.CodeCBORDataFrame.
+type Codec struct{}
+
+// New creates a CBOR Codec for use with client.WithCodec.
+func New() Codec {
+	return Codec{}
+}
+
+// Encode serializes v as CBOR.
+func (Codec) Encode(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+// ContentCode returns protocol.CodeCBORDataFrame.
+func (Codec) ContentCode() byte {
+	return protocol.CodeCBORDataFrame
+}
@@ -0,0 +1,33 @@
+package cbor
+
+import (
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/urso/go-lumber/v2/protocol"
+)
+
+func TestCodecEncode(t *testing.T) {
+	c := New()
+
+	event := map[string]interface{}{"message": "hello", "count": 3}
+	payload, err := c.Encode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := cbor.Unmarshal(payload, &got); err != nil {
+		t.Fatalf("payload did not decode as CBOR: %v", err)
+	}
+	if got["message"] != "hello" {
+		t.Fatalf("message = %v, want hello", got["message"])
+	}
+}
+
+func TestCodecContentCode(t *testing.T) {
+	if got := New().ContentCode(); got != protocol.CodeCBORDataFrame {
+		t.Fatalf("ContentCode = %v, want %v", got, protocol.CodeCBORDataFrame)
+	}
+}
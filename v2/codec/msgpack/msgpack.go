@@ -0,0 +1,28 @@
+// Package msgpack implements a client.Codec serializing events as
+// MessagePack.
+package msgpack
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/urso/go-lumber/v2/protocol"
+)
+
+// Codec encodes events as MessagePack, identifying itself to the server
+// with protocol.CodeMsgpackDataFrame.
+type Codec struct{}
+
+// New creates a MessagePack Codec for use with client.WithCodec.
+func New() Codec {
+	return Codec{}
+}
+
+// Encode serializes v as MessagePack.
+func (Codec) Encode(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// ContentCode returns protocol.CodeMsgpackDataFrame.
+func (Codec) ContentCode() byte {
+	return protocol.CodeMsgpackDataFrame
+}
@@ -0,0 +1,36 @@
+// Package protobuf implements a client.Codec serializing events as
+// Protocol Buffers messages.
+package protobuf
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/urso/go-lumber/v2/protocol"
+)
+
+// Codec encodes events as Protocol Buffers, identifying itself to the
+// server with protocol.CodeProtobufDataFrame. Events passed to Encode
+// must implement proto.Message.
+type Codec struct{}
+
+// New creates a Protobuf Codec for use with client.WithCodec.
+func New() Codec {
+	return Codec{}
+}
+
+// Encode serializes v as a Protocol Buffers message. v must implement
+// proto.Message.
+func (Codec) Encode(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: event of type %T does not implement proto.Message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+// ContentCode returns protocol.CodeProtobufDataFrame.
+func (Codec) ContentCode() byte {
+	return protocol.CodeProtobufDataFrame
+}
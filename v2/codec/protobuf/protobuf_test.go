@@ -0,0 +1,40 @@
+package protobuf
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/urso/go-lumber/v2/protocol"
+)
+
+func TestCodecEncode(t *testing.T) {
+	c := New()
+
+	event := wrapperspb.String("hello")
+	payload, err := c.Encode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got wrapperspb.StringValue
+	if err := proto.Unmarshal(payload, &got); err != nil {
+		t.Fatalf("payload did not decode as a protobuf message: %v", err)
+	}
+	if got.GetValue() != "hello" {
+		t.Fatalf("value = %q, want hello", got.GetValue())
+	}
+}
+
+func TestCodecEncodeNotAProtoMessage(t *testing.T) {
+	if _, err := New().Encode(map[string]interface{}{"message": "hello"}); err == nil {
+		t.Fatal("expected an error encoding a value that does not implement proto.Message")
+	}
+}
+
+func TestCodecContentCode(t *testing.T) {
+	if got := New().ContentCode(); got != protocol.CodeProtobufDataFrame {
+		t.Fatalf("ContentCode = %v, want %v", got, protocol.CodeProtobufDataFrame)
+	}
+}
@@ -0,0 +1,46 @@
+// Package protocol defines the wire-level constants shared by the
+// Lumberjack client and server implementations.
+package protocol
+
+// Version and frame type bytes as used by the Lumberjack protocol.
+//
+// Every frame starts with a version byte followed by a single frame type
+// byte. Version 2 data frames carry JSON encoded payloads by default, or
+// CBOR, MessagePack or Protocol Buffers payloads identified by their own
+// frame type byte, while the legacy version 1 frames encode payloads as
+// flat key/value pairs.
+const (
+	// CodeVersion is the version byte used by the v2 (JSON) protocol.
+	CodeVersion byte = '2'
+
+	// CodeVersion1 is the version byte used by the legacy v1 (data frame) protocol.
+	CodeVersion1 byte = '1'
+
+	// CodeWindowSize marks a window size frame announcing the number of
+	// events about to be sent.
+	CodeWindowSize byte = 'W'
+
+	// CodeCompressed marks a frame whose payload is a zlib compressed
+	// stream of further frames.
+	CodeCompressed byte = 'C'
+
+	// CodeJSONDataFrame marks a v2 data frame with a JSON encoded payload.
+	CodeJSONDataFrame byte = 'J'
+
+	// CodeDataFrame marks a v1 data frame with flat key/value pairs.
+	CodeDataFrame byte = 'D'
+
+	// CodeCBORDataFrame marks a v2 data frame with a CBOR encoded payload.
+	CodeCBORDataFrame byte = 'B'
+
+	// CodeMsgpackDataFrame marks a v2 data frame with a MessagePack
+	// encoded payload.
+	CodeMsgpackDataFrame byte = 'M'
+
+	// CodeProtobufDataFrame marks a v2 data frame with a Protocol Buffers
+	// encoded payload.
+	CodeProtobufDataFrame byte = 'P'
+
+	// CodeACK marks an acknowledgement frame.
+	CodeACK byte = 'A'
+)